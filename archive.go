@@ -0,0 +1,335 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+/**
+ * Archive knows how to unpack itself into a target directory. read,
+ * if non-nil, is incremented with every uncompressed byte written to
+ * disk so callers can drive a progress bar off it. limits bounds how
+ * much Extract will write, so a hostile archive can't path-traverse
+ * out of target or exhaust disk via a zip-bomb-style entry count or
+ * total size.
+ */
+type Archive interface {
+	Extract(target string, read *int64, limits ExtractLimits) error
+}
+
+/**
+ * ExtractLimits caps what a single Extract call will do.
+ */
+type ExtractLimits struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+/**
+ * DefaultExtractLimits is generous enough for a Phrack issue (a few
+ * hundred text files, a few MB uncompressed) while still refusing a
+ * pathological archive.
+ */
+var DefaultExtractLimits = ExtractLimits{
+	MaxBytes: 512 * 1024 * 1024,
+	MaxFiles: 10000,
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte{0x50, 0x4b, 0x03, 0x04}
+)
+
+/**
+ * OpenArchive sniffs the magic bytes of path and returns the Archive
+ * implementation able to extract it. Phrack mirrors the issues in
+ * plain .tar as well as .tar.gz, and some serve .zip, so the format
+ * is detected rather than assumed from the file extension.
+ */
+func OpenArchive(path string) (Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return &tarGzArchive{path: path}, nil
+	case bytes.HasPrefix(header, zipMagic):
+		return &zipArchive{path: path}, nil
+	default:
+		return &tarArchive{path: path}, nil
+	}
+}
+
+/**
+ * tarGzArchive extracts a gzip-compressed tarball.
+ */
+type tarGzArchive struct{ path string }
+
+func (a *tarGzArchive) Extract(target string, read *int64, limits ExtractLimits) error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return extractTar(tar.NewReader(gz), target, read, limits)
+}
+
+/**
+ * tarArchive extracts an uncompressed tarball.
+ */
+type tarArchive struct{ path string }
+
+func (a *tarArchive) Extract(target string, read *int64, limits ExtractLimits) error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractTar(tar.NewReader(f), target, read, limits)
+}
+
+/**
+ * extractTar walks a tar stream, writing each entry under target.
+ * Every entry's path is checked against target before anything is
+ * written, symlink/hardlink targets are checked the same way, and
+ * extraction stops once limits.MaxFiles or limits.MaxBytes is hit.
+ */
+func extractTar(tarReader *tar.Reader, target string, read *int64, limits ExtractLimits) error {
+	fileCount := 0
+	remaining := limits.MaxBytes
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		fileCount++
+		if limits.MaxFiles > 0 && fileCount > limits.MaxFiles {
+			return fmt.Errorf("archive has more than %d entries, refusing to extract", limits.MaxFiles)
+		}
+
+		path, err := safeJoin(target, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink, tar.TypeLink:
+			if err := extractLink(target, path, header); err != nil {
+				return err
+			}
+
+		default:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			var entry io.Reader = tarReader
+			if limits.MaxBytes > 0 {
+				entry = &limitedReader{r: tarReader, remaining: &remaining}
+			}
+			if _, err := writeArchiveFile(path, header.FileInfo().Mode(), entry, read); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * limitedReader caps the total bytes read across however many entries
+ * share it at *remaining, erroring as soon as that budget is exhausted
+ * instead of only after io.Copy finishes -- so a single entry with a
+ * huge declared size can't fill the disk before MaxBytes is noticed.
+ */
+type limitedReader struct {
+	r         io.Reader
+	remaining *int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if *l.remaining <= 0 {
+		return 0, fmt.Errorf("archive exceeds max extracted size")
+	}
+	if int64(len(p)) > *l.remaining {
+		p = p[:*l.remaining]
+	}
+	n, err := l.r.Read(p)
+	*l.remaining -= int64(n)
+	return n, err
+}
+
+/**
+ * extractLink creates the symlink or hardlink at path, first checking
+ * that what it resolves to -- a tar symlink target is relative to the
+ * link's own directory, a hardlink target relative to the archive
+ * root -- still lands inside target.
+ */
+func extractLink(target, path string, header *tar.Header) error {
+	var resolved string
+	if header.Typeflag == tar.TypeSymlink {
+		if filepath.IsAbs(header.Linkname) {
+			resolved = filepath.Clean(header.Linkname)
+		} else {
+			resolved = filepath.Clean(filepath.Join(filepath.Dir(path), header.Linkname))
+		}
+	} else {
+		resolved = filepath.Clean(filepath.Join(target, header.Linkname))
+	}
+
+	if !withinTarget(target, resolved) {
+		return fmt.Errorf("archive entry %q links outside target directory", header.Name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	os.Remove(path)
+
+	if header.Typeflag == tar.TypeSymlink {
+		return os.Symlink(header.Linkname, path)
+	}
+	return os.Link(resolved, path)
+}
+
+/**
+ * zipArchive extracts a zip file.
+ */
+type zipArchive struct{ path string }
+
+func (a *zipArchive) Extract(target string, read *int64, limits ExtractLimits) error {
+	r, err := zip.OpenReader(a.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	fileCount := 0
+	remaining := limits.MaxBytes
+
+	for _, zf := range r.File {
+		fileCount++
+		if limits.MaxFiles > 0 && fileCount > limits.MaxFiles {
+			return fmt.Errorf("archive has more than %d entries, refusing to extract", limits.MaxFiles)
+		}
+
+		path, err := safeJoin(target, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, zf.Mode()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		var entry io.Reader = rc
+		if limits.MaxBytes > 0 {
+			entry = &limitedReader{r: rc, remaining: &remaining}
+		}
+		_, err = writeArchiveFile(path, zf.Mode(), entry, read)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * safeJoin joins target and name the way filepath.Join always would,
+ * then rejects the result if it doesn't stay inside target -- the
+ * defense against "../" entries and absolute paths a hostile archive
+ * might carry.
+ */
+func safeJoin(target, name string) (string, error) {
+	path := filepath.Join(target, name)
+	if !withinTarget(target, path) {
+		return "", fmt.Errorf("archive entry %q escapes target directory", name)
+	}
+	return path, nil
+}
+
+/**
+ * withinTarget reports whether path stays inside target once both are
+ * cleaned, the shared containment check behind safeJoin and
+ * extractLink.
+ */
+func withinTarget(target, path string) bool {
+	targetWithSep := filepath.Clean(target) + string(os.PathSeparator)
+	return strings.HasPrefix(filepath.Clean(path)+string(os.PathSeparator), targetWithSep)
+}
+
+/**
+ * writeArchiveFile copies r to path, bumping read (if non-nil) as
+ * bytes land on disk so the caller can drive a progress bar off it.
+ * Each call gets its own *os.File and closes it immediately on
+ * return, rather than deferring across the whole extraction loop.
+ */
+func writeArchiveFile(path string, mode os.FileMode, r io.Reader, read *int64) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if read != nil {
+		r = io.TeeReader(r, countingWriter{read})
+	}
+	return io.Copy(file, r)
+}
+
+/**
+ * countingWriter atomically adds every byte it "writes" to counter,
+ * letting it be used as the sink half of an io.TeeReader purely for
+ * its side effect.
+ */
+type countingWriter struct{ counter *int64 }
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.counter, int64(len(p)))
+	return len(p), nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		if e.body != "" {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.tar")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestUntar_PathTraversal(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "../escape.txt", body: "pwned"},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, DefaultExtractLimits); err == nil {
+		t.Fatal("expected Extract to reject a \"../\" entry, got no error")
+	}
+}
+
+func TestUntar_AbsolutePathStaysInsideTarget(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "/etc/escape.txt", body: "pwned"},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, DefaultExtractLimits); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	err = filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(path, target) {
+			t.Fatalf("entry %q escaped target %q", path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+}
+
+func TestUntar_SymlinkEscape(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "../../etc/passwd"},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, DefaultExtractLimits); err == nil {
+		t.Fatal("expected Extract to reject a symlink escaping target, got no error")
+	}
+}
+
+func TestUntar_MaxFiles(t *testing.T) {
+	entries := make([]tarEntry, 5)
+	for i := range entries {
+		entries[i] = tarEntry{name: filepath.Join("page", string(rune('a'+i))+".txt"), body: "x"}
+	}
+	archivePath := buildTar(t, entries)
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, ExtractLimits{MaxBytes: 0, MaxFiles: 2}); err == nil {
+		t.Fatal("expected Extract to reject an archive over MaxFiles, got no error")
+	}
+}
+
+func TestUntar_MaxBytes(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "big.txt", body: strings.Repeat("x", 1024)},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, ExtractLimits{MaxBytes: 100, MaxFiles: 10}); err == nil {
+		t.Fatal("expected Extract to reject an entry over MaxBytes, got no error")
+	}
+}
+
+func TestUntar_MultipleFilesUnderBudget(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "1.txt", body: strings.Repeat("a", 900)},
+		{name: "2.txt", body: strings.Repeat("b", 900)},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, ExtractLimits{MaxBytes: 2000, MaxFiles: 10}); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+}
+
+func TestUntar_Legitimate(t *testing.T) {
+	archivePath := buildTar(t, []tarEntry{
+		{name: "1.txt", body: "hello"},
+	})
+	target := t.TempDir()
+
+	arc, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive: %v", err)
+	}
+	if err := arc.Extract(target, nil, DefaultExtractLimits); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(target, "1.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("got %q, want %q", b, "hello")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jroimartin/gocui"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * latestKnownIssue backs the "latest" and "all" range keywords. Phrack
+ * doesn't expose an index we can query for the current issue number,
+ * so this is a best-effort constant that needs bumping by hand as new
+ * issues come out.
+ */
+const latestKnownIssue = "70"
+
+/**
+ * batchWorkers bounds how many issues are fetched concurrently when
+ * loading a range, so a big "all" doesn't hammer the server or open
+ * dozens of sockets at once.
+ */
+const batchWorkers = 4
+
+var rangeDigits = regexp.MustCompile("[^0-9]+")
+
+/**
+ * parseIssueRange turns msg-view input into a list of issue numbers.
+ * Accepts a single issue ("49"), a range ("1-70"), or the keywords
+ * "all" and "latest".
+ */
+func parseIssueRange(spec string) []string {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	switch spec {
+	case "":
+		return nil
+	case "latest":
+		return []string{latestKnownIssue}
+	case "all":
+		spec = "1-" + latestKnownIssue
+	}
+
+	if lo, hi, ok := parseBounds(spec); ok {
+		issues := make([]string, 0, hi-lo+1)
+		for i := lo; i <= hi; i++ {
+			issues = append(issues, strconv.Itoa(i))
+		}
+		return issues
+	}
+
+	// A spec with a "-" that didn't parse as a valid range (e.g. the
+	// reversed "10-5") is a typo, not a different issue number — don't
+	// let the digit-stripping fallback below reinterpret it as one.
+	if strings.Contains(spec, "-") {
+		return nil
+	}
+
+	safe := rangeDigits.ReplaceAllString(spec, "")
+	if safe == "" {
+		return nil
+	}
+	return []string{safe}
+}
+
+func parseBounds(spec string) (lo, hi int, ok bool) {
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lo, errLo := strconv.Atoi(parts[0])
+	hi, errHi := strconv.Atoi(parts[1])
+	if errLo != nil || errHi != nil || lo > hi {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+/**
+ * loadIssueSpec parses spec and kicks off either a single-issue load
+ * or a batch load, without blocking the caller.
+ */
+func loadIssueSpec(spec string) {
+	issues := parseIssueRange(spec)
+	if len(issues) == 0 {
+		return
+	}
+	if len(issues) == 1 {
+		loadSingleIssue(issues[0])
+		return
+	}
+	loadIssueBatch(issues)
+}
+
+func loadSingleIssue(issue string) {
+	p := phracked.get(issue)
+	phracked.setActive(issue)
+	p.init(issue)
+	p.wg.Add(1)
+	go p.load()
+}
+
+/**
+ * loadIssueBatch fetches and unpacks issues concurrently over a
+ * bounded worker pool, with each issue's status lines forwarded to
+ * the shared status view prefixed by issue number so they stack
+ * instead of trampling each other. It returns immediately; the work
+ * happens in the background.
+ */
+func loadIssueBatch(issues []string) {
+	clearStatus()
+	updateTitle(fmt.Sprintf("Loading %d issues...", len(issues)))
+
+	statusCh := make(chan string, 32)
+	jobs := make(chan string)
+
+	var workers sync.WaitGroup
+	for i := 0; i < batchWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for issue := range jobs {
+				loadIssueForBatch(issue, statusCh)
+			}
+		}()
+	}
+
+	go func() {
+		for _, issue := range issues {
+			jobs <- issue
+		}
+		close(jobs)
+		workers.Wait()
+		close(statusCh)
+	}()
+
+	go func() {
+		for line := range statusCh {
+			l := line
+			g.Execute(func(g *gocui.Gui) error {
+				updateStatus(l)
+				return nil
+			})
+		}
+		g.Execute(func(g *gocui.Gui) error {
+			if phracked.currentIssue() == nil {
+				if loaded := phracked.sortedIssues(); len(loaded) > 0 {
+					phracked.setActive(loaded[0])
+				}
+			}
+			updateTitle("Phrack Issues " + strings.Join(issues, ", "))
+			initSide()
+			return nil
+		})
+	}()
+}
+
+/**
+ * loadIssueForBatch runs one issue through the normal fetch/unpack
+ * pipeline, forwarding its status channel into the batch's shared one
+ * with an "Issue N: " prefix so progress lines from several issues
+ * can share the status view.
+ */
+func loadIssueForBatch(issue string, statusCh chan<- string) {
+	p := phracked.get(issue)
+	p.init(issue)
+
+	label := "Issue " + issue + ": "
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for s := range p.status {
+			if s == "done" {
+				return
+			}
+			statusCh <- label + s
+		}
+	}()
+
+	p.fetchIssue()
+	p.writeToFile()
+	p.unpack()
+	p.countPages()
+	p.loadOrBuildIndex()
+	p.status <- "done"
+	<-forwarded
+}
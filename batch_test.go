@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIssueRange(t *testing.T) {
+	cases := []struct {
+		spec string
+		want []string
+	}{
+		{"49", []string{"49"}},
+		{"1-3", []string{"1", "2", "3"}},
+		{"latest", []string{latestKnownIssue}},
+		{"10-5", nil},
+	}
+
+	for _, c := range cases {
+		got := parseIssueRange(c.spec)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseIssueRange(%q) = %v, want %v", c.spec, got, c.want)
+		}
+	}
+}
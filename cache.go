@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+ * cacheRoot returns the directory used to cache downloaded issues,
+ * creating it if it doesn't already exist. os.UserCacheDir resolves
+ * to $XDG_CACHE_HOME (or ~/.cache) on Linux and %LOCALAPPDATA% on
+ * Windows, which is exactly the split the old code never had.
+ */
+func cacheRoot() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Join(base, "phrack-reader")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	return root, nil
+}
+
+/**
+ * cachedTarballPath is where issue's archive lives once downloaded,
+ * whether or not it has been fetched yet.
+ */
+func cachedTarballPath(issue string) (string, error) {
+	root, err := cacheRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, issue+".tar.gz"), nil
+}
+
+func cachedMetaPath(issue string) (string, error) {
+	root, err := cacheRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, issue+".meta"), nil
+}
+
+/**
+ * readCacheMeta loads the cached ETag/Last-Modified pair for an
+ * issue, if any, so fetchIssue can make a conditional GET.
+ */
+func readCacheMeta(issue string) (etag, lastModified string) {
+	path, err := cachedMetaPath(issue)
+	if err != nil {
+		return "", ""
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.SplitN(string(b), "\n", 2)
+	if len(lines) < 2 {
+		return "", ""
+	}
+	return lines[0], lines[1]
+}
+
+/**
+ * writeCacheMeta persists the ETag/Last-Modified pair returned with
+ * a freshly downloaded issue.
+ */
+func writeCacheMeta(issue, etag, lastModified string) error {
+	path, err := cachedMetaPath(issue)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(etag+"\n"+lastModified), 0644)
+}
+
+/**
+ * applyConditionalHeaders adds If-None-Match / If-Modified-Since to
+ * req based on whatever is already cached for issue, so the server
+ * can answer 304 Not Modified instead of resending the tarball.
+ */
+func applyConditionalHeaders(req *http.Request, issue string) {
+	etag, lastModified := readCacheMeta(issue)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+/**
+ * cachedIssues lists the issue numbers that have a cached tarball on
+ * disk, sorted numerically so "2" sorts before "10".
+ */
+func cachedIssues() []string {
+	root, err := cacheRoot()
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var issues []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tar.gz") {
+			issues = append(issues, strings.TrimSuffix(name, ".tar.gz"))
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if len(issues[i]) != len(issues[j]) {
+			return len(issues[i]) < len(issues[j])
+		}
+		return issues[i] < issues[j]
+	})
+	return issues
+}
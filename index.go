@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var wordRe = regexp.MustCompile(`[A-Za-z0-9']+`)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true,
+	"in": true, "is": true, "it": true, "for": true, "on": true, "with": true,
+	"as": true, "at": true, "by": true, "or": true, "that": true, "this": true,
+	"from": true, "be": true, "are": true,
+}
+
+/**
+ * Hit locates a single token occurrence within an issue.
+ */
+type Hit struct {
+	Page int
+	Line int
+	Col  int
+}
+
+/**
+ * Index is an in-memory inverted index over every page of an issue,
+ * built once after unpack() and persisted next to the cached tarball
+ * so reopening the issue doesn't require retokenizing it.
+ */
+type Index struct {
+	Entries map[string][]Hit
+	Lines   map[int][]string
+}
+
+/**
+ * buildIndex tokenizes every .txt page under temp (named "<page>.txt")
+ * on runs of non-word characters, lowercases, and skips stopwords.
+ */
+func buildIndex(temp string) (*Index, error) {
+	files, err := os.ReadDir(temp)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		Entries: make(map[string][]Hit),
+		Lines:   make(map[int][]string),
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".txt") {
+			continue
+		}
+		page, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".txt"))
+		if err != nil {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(temp, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		lines := strings.Split(string(b), "\n")
+		idx.Lines[page] = lines
+		for lineNo, line := range lines {
+			for _, loc := range wordRe.FindAllStringIndex(line, -1) {
+				token := strings.ToLower(line[loc[0]:loc[1]])
+				if stopwords[token] {
+					continue
+				}
+				idx.Entries[token] = append(idx.Entries[token], Hit{Page: page, Line: lineNo + 1, Col: loc[0]})
+			}
+		}
+	}
+
+	return idx, nil
+}
+
+/**
+ * Search looks up query, returning matches sorted by page then line.
+ * A query wrapped in "double quotes" is treated as a phrase: the
+ * first word narrows candidate lines, which are then post-filtered
+ * by checking the phrase actually occurs in the line text. An
+ * unquoted multi-word query is an AND over every token: a line only
+ * matches if it contains every token, not just the first.
+ */
+func (idx *Index) Search(query string) []Hit {
+	query = strings.TrimSpace(query)
+	if len(query) >= 2 && strings.HasPrefix(query, `"`) && strings.HasSuffix(query, `"`) {
+		return idx.searchPhrase(strings.Trim(query, `"`))
+	}
+
+	tokens := wordRe.FindAllString(strings.ToLower(query), -1)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	matches := dedupeHits(idx.Entries[tokens[0]])
+	for _, token := range tokens[1:] {
+		allowed := make(map[[2]int]bool)
+		for _, h := range idx.Entries[token] {
+			allowed[[2]int{h.Page, h.Line}] = true
+		}
+		var narrowed []Hit
+		for _, h := range matches {
+			if allowed[[2]int{h.Page, h.Line}] {
+				narrowed = append(narrowed, h)
+			}
+		}
+		matches = narrowed
+	}
+	return matches
+}
+
+func (idx *Index) searchPhrase(phrase string) []Hit {
+	phrase = strings.ToLower(strings.TrimSpace(phrase))
+	tokens := wordRe.FindAllString(phrase, -1)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var matches []Hit
+	for _, h := range idx.Entries[tokens[0]] {
+		lines := idx.Lines[h.Page]
+		if h.Line-1 >= len(lines) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(lines[h.Line-1]), phrase) {
+			matches = append(matches, h)
+		}
+	}
+	return dedupeHits(matches)
+}
+
+/**
+ * dedupeHits collapses multiple token hits on the same page:line down
+ * to one result and sorts the result set for stable display.
+ */
+func dedupeHits(hits []Hit) []Hit {
+	seen := make(map[[2]int]bool)
+	var results []Hit
+	for _, h := range hits {
+		key := [2]int{h.Page, h.Line}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		results = append(results, h)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Page != results[j].Page {
+			return results[i].Page < results[j].Page
+		}
+		return results[i].Line < results[j].Line
+	})
+	return results
+}
+
+/**
+ * snippet returns the (trimmed) text of the line a Hit points at, for
+ * display in the results view.
+ */
+func snippet(idx *Index, h Hit) string {
+	lines := idx.Lines[h.Page]
+	if h.Line-1 >= len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[h.Line-1])
+}
+
+/**
+ * cachedIndexPath is where an issue's built index is persisted,
+ * alongside its cached tarball.
+ */
+func cachedIndexPath(issue string) (string, error) {
+	root, err := cacheRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, issue+".idx"), nil
+}
+
+func loadIndex(issue string) (*Index, error) {
+	path, err := cachedIndexPath(issue)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx := &Index{}
+	if err := gob.NewDecoder(f).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func saveIndex(issue string, idx *Index) error {
+	path, err := cachedIndexPath(issue)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gob.NewEncoder(f).Encode(idx)
+}
+
+/**
+ * removeCachedIndex deletes a persisted index, if any, so a stale one
+ * built against a previous tarball isn't reused once the tarball is
+ * replaced by a fresh download. Not finding one to delete isn't an
+ * error.
+ */
+func removeCachedIndex(issue string) error {
+	path, err := cachedIndexPath(issue)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func buildTestIndex(lines map[int][]string) *Index {
+	idx := &Index{
+		Entries: make(map[string][]Hit),
+		Lines:   lines,
+	}
+	for page, pageLines := range lines {
+		for lineNo, line := range pageLines {
+			for _, loc := range wordRe.FindAllStringIndex(line, -1) {
+				token := line[loc[0]:loc[1]]
+				idx.Entries[token] = append(idx.Entries[token], Hit{Page: page, Line: lineNo + 1, Col: loc[0]})
+			}
+		}
+	}
+	return idx
+}
+
+func TestSearch_UnquotedMultiWordIsAnd(t *testing.T) {
+	idx := buildTestIndex(map[int][]string{
+		1: {
+			"buffer overflow here",
+			"just buffer no match",
+			"an overflow of buffers",
+		},
+	})
+
+	hits := idx.Search("buffer overflow")
+	if len(hits) != 1 {
+		t.Fatalf("Search(%q) = %d hits, want 1: %+v", "buffer overflow", len(hits), hits)
+	}
+	if hits[0].Line != 1 {
+		t.Fatalf("Search(%q) matched line %d, want line 1", "buffer overflow", hits[0].Line)
+	}
+}
+
+func TestSearch_SingleWord(t *testing.T) {
+	idx := buildTestIndex(map[int][]string{
+		1: {"buffer overflow here", "just buffer no match"},
+	})
+
+	hits := idx.Search("buffer")
+	if len(hits) != 2 {
+		t.Fatalf("Search(%q) = %d hits, want 2", "buffer", len(hits))
+	}
+}
+
+func TestRemoveCachedIndex(t *testing.T) {
+	const issue = "test-remove-cached-index"
+	t.Cleanup(func() { removeCachedIndex(issue) })
+
+	if err := saveIndex(issue, &Index{Entries: map[string][]Hit{}, Lines: map[int][]string{}}); err != nil {
+		t.Fatalf("saveIndex: %v", err)
+	}
+	if _, err := loadIndex(issue); err != nil {
+		t.Fatalf("loadIndex before removal: %v", err)
+	}
+
+	if err := removeCachedIndex(issue); err != nil {
+		t.Fatalf("removeCachedIndex: %v", err)
+	}
+	if _, err := loadIndex(issue); err == nil {
+		t.Fatal("expected loadIndex to fail after removeCachedIndex")
+	}
+
+	if err := removeCachedIndex(issue); err != nil {
+		t.Fatalf("removeCachedIndex on already-missing index: %v", err)
+	}
+}
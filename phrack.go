@@ -1,20 +1,17 @@
 package main
 
 import (
-	"archive/tar"
 	"fmt"
 	"github.com/jroimartin/gocui"
 	"io"
-	"io/ioutil"
 	"log"
 	"math"
 	"net/http"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,17 +26,39 @@ type Phracked struct {
 	tempPrefix string
 	temp       string
 	pages      int
-	tgz        *os.File
 	filePath   string
+	fromCache  bool
+	noCache    bool
 	response   *http.Response
+	indexMu    sync.Mutex
+	index      *Index
 }
 
 /**
- *  Make sure we clean up after ourselves.
+ * setIndex and getIndex guard index: it's written from whichever
+ * goroutine loads this issue (including a batch worker in
+ * loadIssueForBatch) and read from searchAll/runSearch whenever the
+ * user submits a search, possibly while a load is still in flight.
+ */
+func (p *Phracked) setIndex(idx *Index) {
+	p.indexMu.Lock()
+	p.index = idx
+	p.indexMu.Unlock()
+}
+
+func (p *Phracked) getIndex() *Index {
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+	return p.index
+}
+
+/**
+ *  Make sure we clean up after ourselves. The cached tarball in
+ *  filePath is left in place on purpose -- only the unpacked temp
+ *  dir is scratch space.
  */
 func (p *Phracked) clean() {
 	if p.temp != "" {
-		p.tgz.Close()
 		os.RemoveAll(p.temp)
 	}
 }
@@ -54,22 +73,23 @@ func (p *Phracked) init(issue string) {
 	p.issue = issue
 	p.url = "http://www.phrack.org/archives/tgz/phrack" + p.issue + ".tar.gz"
 	p.tempPrefix = "issue-" + p.issue + "-"
-	p.temp, err = ioutil.TempDir("", p.tempPrefix)
+	p.temp, err = os.MkdirTemp("", p.tempPrefix)
 	if err != nil {
 		cleanFatal(err)
 	}
-	p.filePath = p.temp + "/" + p.issue + ".tar.gz"
-	p.tgz, err = os.Create(p.filePath)
+	p.filePath, err = cachedTarballPath(p.issue)
 	if err != nil {
 		cleanFatal(err)
 	}
+	p.fromCache = false
+	p.noCache = false
 }
 
 /**
  * Count the pages for the current Phrack issue.
  */
 func (p *Phracked) countPages() {
-	files, err := ioutil.ReadDir(p.temp)
+	files, err := os.ReadDir(p.temp)
 	if err != nil {
 		cleanFatal(err)
 	}
@@ -116,66 +136,232 @@ func (p *Phracked) load() {
 }
 
 /**
- * Unpackes the phracked issue pushing status to channel.
+ * Unpacks the phracked issue pushing status to channel. The archive
+ * format is sniffed from its magic bytes, so mirrors serving plain
+ * .tar or .zip files work just as well as the usual .tar.gz. Progress
+ * is driven off cumulative uncompressed bytes written vs. the size of
+ * the archive on disk, which is approximate for compressed formats
+ * but good enough to show the extraction is moving. If fetchIssue
+ * fell back to the cache (offline mode, or a network failure) and
+ * there's nothing cached for this issue either, sets noCache instead
+ * of failing, so the caller can degrade to the cached-issue list.
  */
 func (p *Phracked) unpack() {
-	p.status <- "Unpacking tar.gz..."
-	err := untar(p.filePath, p.temp)
+	if _, err := os.Stat(p.filePath); err != nil {
+		p.status <- "\nNo cached copy of issue " + p.issue + " available\n"
+		p.noCache = true
+		return
+	}
+
+	arc, err := OpenArchive(p.filePath)
+	if err != nil {
+		cleanFatal(err)
+	}
+
+	var total int64
+	if info, err := os.Stat(p.filePath); err == nil {
+		total = info.Size()
+	}
+
+	var read int64
+	done := make(chan struct{})
+	go progressBar("Unpacking", func() int64 { return atomic.LoadInt64(&read) }, total, p.status, done)
+
+	err = arc.Extract(p.temp, &read, DefaultExtractLimits)
+	close(done)
 	if err != nil {
 		cleanFatal(err)
 	}
-	p.status <- "Issue unpacked\n"
+	p.status <- "\nIssue unpacked\n"
 }
 
 /**
- * Writed the downloaded phracked issue pushing status to channel.
+ * Writes the downloaded phracked issue to the cache, pushing status
+ * to channel. Skipped entirely when fetchIssue resolved to a cached
+ * copy, since there is nothing new to write. Reports download
+ * progress off Content-Length, degrading to a byte counter when the
+ * server doesn't send one. Also drops any index persisted for a
+ * previous copy of this issue, so loadOrBuildIndex rebuilds against
+ * the pages just written instead of reusing stale line numbers.
  */
 func (p *Phracked) writeToFile() {
-	_, err := io.Copy(p.tgz, p.response.Body)
-	p.status <- "Wrote to " + p.filePath + "\n"
+	if p.fromCache {
+		return
+	}
+	defer p.response.Body.Close()
+
+	f, err := os.Create(p.filePath)
+	if err != nil {
+		cleanFatal(err)
+	}
+	defer f.Close()
+
+	reader := &progressReader{Reader: p.response.Body}
+	done := make(chan struct{})
+	go progressBar("Downloading", func() int64 { return atomic.LoadInt64(&reader.read) }, p.response.ContentLength, p.status, done)
+
+	_, err = io.Copy(f, reader)
+	close(done)
+	p.status <- "\nWrote to " + p.filePath + "\n"
 	if err != nil {
 		cleanFatal(err)
 	}
+	writeCacheMeta(p.issue, p.response.Header.Get("ETag"), p.response.Header.Get("Last-Modified"))
+	removeCachedIndex(p.issue)
 }
 
 /**
- * Fetches the phracked issue pushing status to channel.
+ * maxFetchAttempts bounds the 429/5xx retry loop in fetchIssue so a
+ * persistently unhappy server degrades to the cache instead of
+ * retrying forever.
+ */
+const maxFetchAttempts = 5
+
+/**
+ * Fetches the phracked issue pushing status to channel. In --offline
+ * mode, or when the network request fails, falls back to whatever is
+ * already cached for this issue. A conditional GET (If-None-Match /
+ * If-Modified-Since) avoids re-downloading issues that haven't changed.
+ * 429 and 5xx responses are retried with exponential backoff, honoring
+ * a Retry-After header when the server sends one.
  */
 func (p *Phracked) fetchIssue() {
-	var err error
+	if offlineMode {
+		p.status <- "Offline mode, using cached copy of issue " + p.issue + "\n"
+		p.fromCache = true
+		return
+	}
+
 	p.status <- "Fetching " + p.url + "..."
-	p.response, err = http.Get(p.url)
-	if err != nil {
-		cleanFatal(err)
+
+	backoff := time.Second
+	for attempt := 1; attempt <= maxFetchAttempts; attempt++ {
+		req, err := http.NewRequest("GET", p.url, nil)
+		if err != nil {
+			cleanFatal(err)
+		}
+		applyConditionalHeaders(req, p.issue)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			p.status <- "\nNetwork error, falling back to cache...\n"
+			p.fromCache = true
+			return
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified:
+			resp.Body.Close()
+			p.status <- "\nIssue unchanged, using cache...\n"
+			p.fromCache = true
+			return
+
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			wait := retryAfter(resp.Header, backoff)
+			resp.Body.Close()
+			p.status <- fmt.Sprintf("\nServer busy (%d), retrying in %s...\n", resp.StatusCode, wait)
+			time.Sleep(wait)
+			backoff *= 2
+			continue
+
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			p.status <- "\nNetwork error, falling back to cache...\n"
+			p.fromCache = true
+			return
+
+		default:
+			p.response = resp
+			p.status <- "\nDownload Complete...\n"
+			return
+		}
+	}
+
+	p.status <- "\nGiving up, falling back to cache...\n"
+	p.fromCache = true
+}
+
+/**
+ * retryAfter prefers the server's Retry-After header (in seconds)
+ * over the caller's own backoff estimate.
+ */
+func retryAfter(h http.Header, fallback time.Duration) time.Duration {
+	if ra := h.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Duration(secs) * time.Second
+		}
 	}
-	p.status <- "\nDownload Complete...\n"
+	return fallback
 }
 
 /**
- * Builds/updated the UI pushing status to channel.
+ * Builds/updated the UI pushing status to channel. If unpack found
+ * nothing cached for this issue, there are no pages to count or index,
+ * so this just leaves the side view to show the cached-issue list.
  */
 func (p *Phracked) buildUI() {
 	p.status <- "Building UI\n"
+	if p.noCache {
+		initSide()
+		return
+	}
 	p.countPages()
+	p.loadOrBuildIndex()
 	initSide()
 }
 
+/**
+ * loadOrBuildIndex reuses the index cached alongside this issue's
+ * tarball if one is there, otherwise tokenizes the freshly unpacked
+ * pages and persists the result for next time.
+ */
+func (p *Phracked) loadOrBuildIndex() {
+	if idx, err := loadIndex(p.issue); err == nil {
+		p.setIndex(idx)
+		return
+	}
+
+	idx, err := buildIndex(p.temp)
+	if err != nil {
+		cleanFatal(err)
+	}
+	p.setIndex(idx)
+	saveIndex(p.issue, idx)
+}
+
 /**
  *  Figure out what issue to start with.
  */
 func init() {
 	issue := "1"
-	if len(os.Args) > 1 {
-		issue = os.Args[1]
+	for _, arg := range os.Args[1:] {
+		switch {
+		case arg == "--offline" || arg == "-offline":
+			offlineMode = true
+		case strings.HasPrefix(arg, "-test."):
+			// flags go test passes to its own binary, not ours
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "phrack: unrecognized flag %q\n", arg)
+			os.Exit(2)
+		default:
+			issue = arg
+		}
 	}
-	phracked.init(issue)
+
+	p := phracked.get(issue)
+	phracked.setActive(issue)
+	p.init(issue)
 }
 
 /**
- *  Creating in global scope for ease of access.
+ *  Creating in global scope for ease of access. phracked is a
+ *  registry rather than a single issue so that batch loads and
+ *  cross-issue search have somewhere to keep every issue fetched
+ *  this run.
  */
-var phracked = new(Phracked)
+var phracked = &Registry{issues: make(map[string]*Phracked)}
 var g = gocui.NewGui()
+var offlineMode bool
 
 func main() {
 
@@ -189,15 +375,16 @@ func main() {
 		cleanFatal(err)
 	}
 
-	defer phracked.clean()
-	phracked.wg.Add(1)
-	go phracked.load()
+	defer phracked.cleanAll()
+	p := phracked.currentIssue()
+	p.wg.Add(1)
+	go p.load()
 
 	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
 		cleanFatal(err)
 	}
 
-	phracked.wg.Wait()
+	p.wg.Wait()
 }
 
 /**
@@ -245,7 +432,7 @@ func updateStatus(status string) {
 }
 
 func cleanFatal(v ...interface{}) {
-  phracked.clean()
+  phracked.cleanAll()
   log.Fatal(v...)
 }
 
@@ -321,12 +508,13 @@ func cursorSelect(g *gocui.Gui, v *gocui.View) error {
 		if err != nil {
 			l = ""
 		}
-		if l == "load" {
+		switch {
+		case l == "load":
 			maxX, maxY := g.Size()
 			if msg, err := g.SetView("msg", maxX/2-30, maxY/2, maxX/2+30, maxY/2+2); err != nil {
 				msg.Editable = true
 				msg.Highlight = true
-				msg.Title = "Issue Number To Load"
+				msg.Title = "Issue # / Range (1-70, all, latest)"
 				if err != gocui.ErrUnknownView {
 					return err
 				}
@@ -335,7 +523,16 @@ func cursorSelect(g *gocui.Gui, v *gocui.View) error {
 				}
 			}
 			return nil
-		} else {
+		case strings.HasPrefix(l, "issue "):
+			loadSingleIssue(strings.TrimPrefix(l, "issue "))
+		case strings.HasPrefix(l, "Issue "):
+			// tree header line, nothing to open
+		case strings.HasPrefix(l, "  "):
+			if issue := issueForRow(v, cy); issue != "" {
+				phracked.setActive(issue)
+				updateMainFile(strings.TrimSpace(l) + ".txt")
+			}
+		default:
 			updateMainFile(l + ".txt")
 		}
 
@@ -343,31 +540,137 @@ func cursorSelect(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+/**
+ * issueForRow walks a side-view tree upward from row to find the
+ * nearest "Issue N" header, returning N.
+ */
+func issueForRow(v *gocui.View, row int) string {
+	for y := row; y >= 0; y-- {
+		l, err := v.Line(y)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(l, "Issue ") {
+			return strings.TrimPrefix(l, "Issue ")
+		}
+	}
+	return ""
+}
+
 func loadIssue(g *gocui.Gui, v *gocui.View) error {
 	v.Rewind()
-	vb := v.ViewBuffer()
-	reg, err := regexp.Compile("[^0-9]+")
-	if err != nil {
-		cleanFatal(err)
-	}
-	safer := reg.ReplaceAllString(vb, "")
+	spec := strings.TrimSpace(v.ViewBuffer())
 	if err := g.DeleteView("msg"); err != nil {
 		return err
 	}
 	if err := g.SetCurrentView("main"); err != nil {
 		return err
 	}
-	phracked.init(safer)
-	phracked.wg.Add(1)
-	go phracked.load()
+	loadIssueSpec(spec)
 	return nil
 }
 
 func quit(g *gocui.Gui, v *gocui.View) error {
-	phracked.status <- "done"
+	if p := phracked.currentIssue(); p != nil && p.status != nil {
+		select {
+		case p.status <- "done":
+		default:
+		}
+	}
 	return gocui.ErrQuit
 }
 
+/**
+ * openSearch pops up the "search" view for a query, bound to "/".
+ */
+func openSearch(g *gocui.Gui, v *gocui.View) error {
+	maxX, maxY := g.Size()
+	if sv, err := g.SetView("search", maxX/2-30, maxY/2, maxX/2+30, maxY/2+2); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		sv.Editable = true
+		sv.Highlight = true
+		sv.Title = `Search (use "quotes" for a phrase)`
+	}
+	return g.SetCurrentView("search")
+}
+
+/**
+ * runSearch reads the query out of the "search" view, runs it against
+ * every loaded issue's index, and lists "issue:page:line: text" hits
+ * in a "results" view.
+ */
+func runSearch(g *gocui.Gui, v *gocui.View) error {
+	v.Rewind()
+	query := strings.TrimSpace(v.ViewBuffer())
+	if err := g.DeleteView("search"); err != nil {
+		return err
+	}
+	if err := g.SetCurrentView("main"); err != nil {
+		return err
+	}
+	if query == "" {
+		return nil
+	}
+
+	results := phracked.searchAll(query)
+	maxX, maxY := g.Size()
+	rv, err := g.SetView("results", maxX/2-35, maxY/2-10, maxX/2+35, maxY/2+10)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	rv.Clear()
+	rv.Highlight = true
+
+	total := 0
+	for _, r := range results {
+		p := phracked.get(r.issue)
+		for _, h := range r.hits {
+			fmt.Fprintf(rv, "%s:%d:%d: %s\n", r.issue, h.Page, h.Line, snippet(p.getIndex(), h))
+			total++
+		}
+	}
+	rv.Title = fmt.Sprintf("%d hits for %q", total, query)
+	if total == 0 {
+		fmt.Fprintln(rv, "No matches")
+	}
+	return g.SetCurrentView("results")
+}
+
+/**
+ * jumpToResult parses the selected "issue:page:line: text" result
+ * line and scrolls the main view to that exact issue, page and line.
+ */
+func jumpToResult(g *gocui.Gui, v *gocui.View) error {
+	_, cy := v.Cursor()
+	line, err := v.Line(cy)
+	if err != nil {
+		return nil
+	}
+
+	parts := strings.SplitN(line, ":", 4)
+	if len(parts) < 3 {
+		return nil
+	}
+	issue := parts[0]
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil
+	}
+	lineNo, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil
+	}
+
+	if err := g.DeleteView("results"); err != nil {
+		return err
+	}
+	phracked.setActive(issue)
+	updateMainFileAt(strconv.Itoa(page)+".txt", lineNo)
+	return g.SetCurrentView("main")
+}
+
 func keybindings(g *gocui.Gui) error {
 	if err := g.SetKeybinding("side", gocui.KeyTab, gocui.ModNone, nextView); err != nil {
 		return err
@@ -393,55 +696,36 @@ func keybindings(g *gocui.Gui) error {
 	if err := g.SetKeybinding("msg", gocui.KeyEnter, gocui.ModNone, loadIssue); err != nil {
 		return err
 	}
-	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); err != nil {
+	if err := g.SetKeybinding("main", '/', gocui.ModNone, openSearch); err != nil {
 		return err
 	}
-	return nil
-}
-
-/**
- * untars a tarbell into target directory.
- */
-func untar(tarball, target string) error {
-	reader, err := os.Open(tarball)
-	if err != nil {
+	if err := g.SetKeybinding("side", '/', gocui.ModNone, openSearch); err != nil {
 		return err
 	}
-	defer reader.Close()
-	tarReader := tar.NewReader(reader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		path := filepath.Join(target, header.Name)
-		info := header.FileInfo()
-		if info.IsDir() {
-			if err = os.MkdirAll(path, info.Mode()); err != nil {
-				return err
-			}
-			continue
-		}
-
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(file, tarReader)
-		if err != nil {
-			return err
-		}
+	if err := g.SetKeybinding("search", gocui.KeyEnter, gocui.ModNone, runSearch); err != nil {
+		return err
+	}
+	if err := g.SetKeybinding("results", gocui.KeyEnter, gocui.ModNone, jumpToResult); err != nil {
+		return err
+	}
+	if err := g.SetKeybinding("results", gocui.KeyArrowDown, gocui.ModNone, cursorDown); err != nil {
+		return err
+	}
+	if err := g.SetKeybinding("results", gocui.KeyArrowUp, gocui.ModNone, cursorUp); err != nil {
+		return err
+	}
+	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); err != nil {
+		return err
 	}
 	return nil
 }
 
 /**
- * Initializes the side view with the proper page count.
+ * Initializes the side view with the proper page count. In --offline
+ * mode the cached issues are listed above the page list so one can be
+ * picked without touching the network. Once more than one issue has
+ * been loaded this run, the flat page list becomes a two-level tree
+ * of "Issue N" headers over their indented pages.
  */
 func initSide() {
 	v, err := g.View("side")
@@ -450,22 +734,44 @@ func initSide() {
 	}
 	v.Clear()
 	fmt.Fprintf(v, "%s\n", "load")
-	for i := 1; i <= phracked.pages; i++ {
-		fmt.Fprintf(v, "%s\n", strconv.Itoa(i))
+	if offlineMode {
+		for _, issue := range cachedIssues() {
+			fmt.Fprintf(v, "issue %s\n", issue)
+		}
 	}
+
+	issues := phracked.sortedIssues()
+	if len(issues) > 1 {
+		for _, issue := range issues {
+			p := phracked.get(issue)
+			fmt.Fprintf(v, "Issue %s\n", issue)
+			for i := 1; i <= p.pages; i++ {
+				fmt.Fprintf(v, "  %s\n", strconv.Itoa(i))
+			}
+		}
+	} else if p := phracked.currentIssue(); p != nil {
+		for i := 1; i <= p.pages; i++ {
+			fmt.Fprintf(v, "%s\n", strconv.Itoa(i))
+		}
+	}
+
 	updateMainFile("1.txt")
 }
 
 /**
- * Updates the Main view with phracked file.
+ * Updates the Main view with the active issue's file.
  */
 func updateMainFile(path string) {
-	path = phracked.temp + "/" + path
+	p := phracked.currentIssue()
+	if p == nil {
+		return
+	}
+	path = p.temp + "/" + path
 	mainView, err := g.View("main")
 	if err != nil {
 		cleanFatal(err)
 	}
-	b, err := ioutil.ReadFile(path)
+	b, err := os.ReadFile(path)
 	if err != nil {
 		mainView.Clear()
 		fmt.Fprintf(mainView, "Can't find file...")
@@ -479,3 +785,26 @@ func updateMainFile(path string) {
 		}
 	}
 }
+
+/**
+ * updateMainFileAt loads path into the main view like updateMainFile,
+ * then scrolls so line (1-indexed) is roughly centered and the cursor
+ * sits on it -- used to jump to a search hit.
+ */
+func updateMainFileAt(path string, line int) {
+	updateMainFile(path)
+
+	mainView, err := g.View("main")
+	if err != nil {
+		cleanFatal(err)
+	}
+	_, maxY := mainView.Size()
+	oy := line - maxY/2
+	if oy < 0 {
+		oy = 0
+	}
+	if err := mainView.SetOrigin(0, oy); err != nil {
+		return
+	}
+	mainView.SetCursor(0, line-1-oy)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUnpack_NoCachedCopyDoesNotFatal(t *testing.T) {
+	p := &Phracked{
+		status:   make(chan string, 1),
+		issue:    "99",
+		temp:     t.TempDir(),
+		filePath: filepath.Join(t.TempDir(), "99.tar.gz"), // never written
+	}
+
+	p.unpack()
+
+	if !p.noCache {
+		t.Fatal("expected noCache to be set when the cached tarball doesn't exist")
+	}
+	select {
+	case <-p.status:
+	default:
+		t.Fatal("expected unpack to report the missing cache on the status channel")
+	}
+}
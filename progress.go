@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * progressReader wraps an io.Reader, counting bytes read so a
+ * ticker-driven goroutine can report download progress concurrently
+ * with the io.Copy consuming it.
+ */
+type progressReader struct {
+	io.Reader
+	read int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	atomic.AddInt64(&r.read, int64(n))
+	return n, err
+}
+
+/**
+ * progressBar renders a textual bar like
+ * "Downloading [=====>     ] 42%  512KB/1.2MB  230KB/s  ETA 3s" to
+ * status every 100ms until done is closed, then renders one final
+ * time. speed is an EWMA over the last couple of ticks so it doesn't
+ * jitter between samples. When total is unknown (<= 0) it degrades
+ * to a plain byte counter.
+ */
+func progressBar(label string, current func() int64, total int64, status chan<- string, done <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var lastRead int64
+	lastTick := start
+	var speed float64
+
+	render := func() {
+		read := current()
+		now := time.Now()
+		if elapsed := now.Sub(lastTick).Seconds(); elapsed > 0 {
+			instant := float64(read-lastRead) / elapsed
+			const alpha = 0.3
+			speed = alpha*instant + (1-alpha)*speed
+		}
+		lastRead = read
+		lastTick = now
+
+		status <- "\r" + formatProgress(label, read, total, speed)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			render()
+		case <-done:
+			render()
+			return
+		}
+	}
+}
+
+/**
+ * formatProgress renders a single progress line for the given byte
+ * counts and speed.
+ */
+func formatProgress(label string, read, total int64, bytesPerSec float64) string {
+	const width = 20
+
+	if total <= 0 {
+		return fmt.Sprintf("%s %s  %s/s", label, formatBytes(read), formatBytes(int64(bytesPerSec)))
+	}
+
+	pct := float64(read) / float64(total)
+	if pct > 1 {
+		pct = 1
+	}
+	filled := int(pct * width)
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+
+	eta := "?"
+	if bytesPerSec > 0 {
+		remaining := float64(total-read) / bytesPerSec
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta = fmt.Sprintf("%ds", int(remaining))
+	}
+
+	return fmt.Sprintf("%s [%s] %3d%%  %s/%s  %s/s  ETA %s",
+		label, bar, int(pct*100), formatBytes(read), formatBytes(total), formatBytes(int64(bytesPerSec)), eta)
+}
+
+/**
+ * formatBytes renders a byte count as e.g. "512KB" or "1.2MB".
+ */
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/**
+ * Registry holds every issue that has been loaded this run, keyed by
+ * issue number, so the side view's tree and cross-issue search can
+ * see all of them at once. "active" is whichever issue the main view
+ * is currently showing.
+ */
+type Registry struct {
+	mu     sync.Mutex
+	issues map[string]*Phracked
+	active string
+}
+
+/**
+ * get returns the Phracked for issue, creating an empty one the
+ * first time it's asked for.
+ */
+func (r *Registry) get(issue string) *Phracked {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.issues[issue]
+	if !ok {
+		p = new(Phracked)
+		r.issues[issue] = p
+	}
+	return p
+}
+
+func (r *Registry) setActive(issue string) {
+	r.mu.Lock()
+	r.active = issue
+	r.mu.Unlock()
+}
+
+/**
+ * currentIssue is the Phracked the main view is showing, or nil
+ * before anything has been loaded.
+ */
+func (r *Registry) currentIssue() *Phracked {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.issues[r.active]
+}
+
+/**
+ * sortedIssues lists every loaded issue number, sorted numerically.
+ */
+func (r *Registry) sortedIssues() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	issues := make([]string, 0, len(r.issues))
+	for issue := range r.issues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		ni, ei := strconv.Atoi(issues[i])
+		nj, ej := strconv.Atoi(issues[j])
+		if ei == nil && ej == nil {
+			return ni < nj
+		}
+		return issues[i] < issues[j]
+	})
+	return issues
+}
+
+func (r *Registry) cleanAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.issues {
+		p.clean()
+	}
+}
+
+/**
+ * issueHits is one issue's worth of search results.
+ */
+type issueHits struct {
+	issue string
+	hits  []Hit
+}
+
+/**
+ * searchAll runs query against every loaded issue that has an index
+ * built, in issue order, skipping issues with no hits.
+ */
+func (r *Registry) searchAll(query string) []issueHits {
+	var results []issueHits
+	for _, issue := range r.sortedIssues() {
+		p := r.get(issue)
+		idx := p.getIndex()
+		if idx == nil {
+			continue
+		}
+		if hits := idx.Search(query); len(hits) > 0 {
+			results = append(results, issueHits{issue: issue, hits: hits})
+		}
+	}
+	return results
+}
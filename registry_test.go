@@ -0,0 +1,30 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPhracked_IndexConcurrentAccess(t *testing.T) {
+	r := &Registry{issues: make(map[string]*Phracked)}
+	p := r.get("1")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			p.setIndex(&Index{Entries: make(map[string][]Hit), Lines: make(map[int][]string)})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.searchAll("anything")
+		}
+	}()
+
+	wg.Wait()
+}